@@ -0,0 +1,57 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// cache is shared across every session and view, and reads files directly
+// from disk -- ones not open in the editor and therefore not tracked by any
+// snapshot. It holds no file contents itself: each getFile call reads the
+// file from disk again.
+type cache struct{}
+
+// getFile reads the file at uri directly from disk. It is used by
+// (*snapshot).goSum to consult a go.sum that may not be open in the editor,
+// without adding a handle for it to the snapshot's own file set.
+func (c *cache) getFile(ctx context.Context, uri span.URI) (source.FileHandle, error) {
+	content, err := os.ReadFile(uri.Filename())
+	if err != nil {
+		return nil, err
+	}
+	return &diskFile{uri: uri, content: content}, nil
+}
+
+// diskFile is the source.FileHandle for a file read directly off disk,
+// rather than through the overlay of edits a session tracks for open files.
+type diskFile struct {
+	uri     span.URI
+	content []byte
+}
+
+func (d *diskFile) URI() span.URI { return d.uri }
+
+// Kind returns the file's FileKind. cache.getFile is only ever used to
+// read a go.sum alongside a go.mod, so this is never consulted today, but
+// it's left as the honest unknown value rather than misreporting the kind.
+func (d *diskFile) Kind() source.FileKind { return source.FileKind(0) }
+
+func (d *diskFile) Read() ([]byte, error) { return d.content, nil }
+
+func (d *diskFile) Saved() bool { return true }
+
+func (d *diskFile) FileIdentity() source.FileIdentity {
+	return source.FileIdentity{
+		URI:  d.uri,
+		Hash: fmt.Sprintf("%x", sha256.Sum256(d.content)),
+	}
+}
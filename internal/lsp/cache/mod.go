@@ -17,6 +17,7 @@ import (
 
 	"golang.org/x/mod/modfile"
 	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 	"golang.org/x/tools/internal/event"
 	"golang.org/x/tools/internal/gocommand"
 	"golang.org/x/tools/internal/lsp/debug/tag"
@@ -122,6 +123,39 @@ func sumFilename(modURI span.URI) string {
 	return strings.TrimSuffix(modURI.Filename(), ".mod") + ".sum"
 }
 
+// hasGoSumEntry reports whether the go.sum file contents in sum already
+// contain a line recording the hash of mod, either for the module zip
+// itself or for its go.mod file.
+func hasGoSumEntry(sum []byte, mod module.Version) bool {
+	prefix := mod.Path + " " + mod.Version + " "
+	modPrefix := mod.Path + " " + mod.Version + "/go.mod "
+	for _, line := range strings.Split(string(sum), "\n") {
+		if strings.HasPrefix(line, prefix) || strings.HasPrefix(line, modPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ModDownload runs `go mod download` for the given module, so that the
+// go.sum entry it requires is added to the module's go.sum file. It is the
+// handler invoked by the source.CommandModDownload code action offered for
+// "missing go.sum entry" diagnostics.
+func (s *snapshot) ModDownload(ctx context.Context, fh source.FileHandle, args []string) error {
+	inv := &gocommand.Invocation{
+		Verb:       "mod",
+		Args:       append([]string{"download"}, args...),
+		WorkingDir: filepath.Dir(fh.URI().Filename()),
+	}
+	if _, err := s.RunGoCommandDirect(ctx, source.Normal|source.AllowNetwork, inv); err != nil {
+		return err
+	}
+	// The snapshot is not refreshed here. goSum reads go.sum straight off
+	// disk only when the snapshot does not track that file; if it does,
+	// goSum keeps returning the snapshot's copy of go.sum.
+	return nil
+}
+
 // modKey is uniquely identifies cached data for `go mod why` or dependencies
 // to upgrade.
 type modKey struct {
@@ -135,6 +169,8 @@ type modAction int
 const (
 	why modAction = iota
 	upgrade
+	downgrade
+	whyIndirect
 )
 
 type modWhyHandle struct {
@@ -290,6 +326,21 @@ func (s *snapshot) ModUpgrade(ctx context.Context, fh source.FileHandle) (map[st
 			// (see golang/go#38711).
 			inv.ModFlag = "readonly"
 		}
+		// If this module is part of a go.work workspace, load every module
+		// named by a `use` directive as a first-class module of the view,
+		// then honor any workspace-level replace directives by running
+		// against a temp go.mod with them applied, rather than the real one
+		// on disk.
+		if _, err := snapshot.WorkspaceModFiles(ctx, fh.URI()); err != nil {
+			return &modUpgradeData{err: err}
+		}
+		if tmp, err := snapshot.tempWorkspaceModFile(ctx, fh, pm); err != nil {
+			return &modUpgradeData{err: err}
+		} else if tmp != "" {
+			defer os.Remove(tmp)
+			// Flags must precede the "all" pattern.
+			inv.Args = append([]string{"-modfile=" + tmp}, inv.Args...)
+		}
 		stdout, err := snapshot.RunGoCommandDirect(ctx, source.Normal|source.AllowNetwork, inv)
 		if err != nil {
 			return &modUpgradeData{err: err}
@@ -327,6 +378,217 @@ func (s *snapshot) ModUpgrade(ctx context.Context, fh source.FileHandle) (map[st
 	return muh.upgrades(ctx, s)
 }
 
+type modDowngradeHandle struct {
+	handle *memoize.Handle
+}
+
+type modDowngradeData struct {
+	// downgrades maps modules to the list of older tagged versions they
+	// could be downgraded to, ordered oldest to newest.
+	downgrades map[string][]string
+
+	err error
+}
+
+func (mdh *modDowngradeHandle) downgrades(ctx context.Context, snapshot *snapshot) (map[string][]string, error) {
+	v, err := mdh.handle.Get(ctx, snapshot.generation, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	data := v.(*modDowngradeData)
+	return data.downgrades, data.err
+}
+
+// moduleVersions describes the known versions of a module, as reported by
+// `go list -m -json -versions`.
+type moduleVersions struct {
+	Path     string
+	Version  string
+	Versions []string
+}
+
+// olderVersions returns the entries of versions that are older, in semver
+// order, than current.
+func olderVersions(current string, versions []string) []string {
+	var older []string
+	for _, v := range versions {
+		if semver.Compare(v, current) < 0 {
+			older = append(older, v)
+		}
+	}
+	return older
+}
+
+// ModDowngrade returns the older tagged versions available for each
+// dependency of the go.mod file at fh.URI(), to power a "downgrade to..."
+// code lens on each require line.
+func (s *snapshot) ModDowngrade(ctx context.Context, fh source.FileHandle) (map[string][]string, error) {
+	if fh.Kind() != source.Mod {
+		return nil, fmt.Errorf("%s is not a go.mod file", fh.URI())
+	}
+	if handle := s.getModDowngradeHandle(fh.URI()); handle != nil {
+		return handle.downgrades(ctx, s)
+	}
+	key := modKey{
+		sessionID: s.view.session.id,
+		env:       hashEnv(s),
+		mod:       fh.FileIdentity(),
+		view:      s.view.rootURI.Filename(),
+		verb:      downgrade,
+	}
+	h := s.generation.Bind(key, func(ctx context.Context, arg memoize.Arg) interface{} {
+		ctx, done := event.Start(ctx, "cache.ModDowngradeHandle", tag.URI.Of(fh.URI()))
+		defer done()
+
+		snapshot := arg.(*snapshot)
+
+		pm, err := snapshot.ParseMod(ctx, fh)
+		if err != nil {
+			return &modDowngradeData{err: err}
+		}
+		// No requires to downgrade.
+		if len(pm.File.Require) == 0 {
+			return &modDowngradeData{}
+		}
+		inv := &gocommand.Invocation{
+			Verb:       "list",
+			Args:       []string{"-m", "-json", "-versions"},
+			WorkingDir: filepath.Dir(fh.URI().Filename()),
+		}
+		for _, req := range pm.File.Require {
+			inv.Args = append(inv.Args, req.Mod.Path)
+		}
+		stdout, err := snapshot.RunGoCommandDirect(ctx, source.Normal|source.AllowNetwork, inv)
+		if err != nil {
+			return &modDowngradeData{err: err}
+		}
+		downgrades := make(map[string][]string)
+		dec := json.NewDecoder(stdout)
+		for {
+			var m moduleVersions
+			if err := dec.Decode(&m); err == io.EOF {
+				break
+			} else if err != nil {
+				return &modDowngradeData{err: err}
+			}
+			if older := olderVersions(m.Version, m.Versions); len(older) > 0 {
+				downgrades[m.Path] = older
+			}
+		}
+		return &modDowngradeData{downgrades: downgrades}
+	}, nil)
+	mdh := &modDowngradeHandle{handle: h}
+	s.mu.Lock()
+	s.modDowngradeHandles[fh.URI()] = mdh
+	s.mu.Unlock()
+
+	return mdh.downgrades(ctx, s)
+}
+
+type modWhyIndirectHandle struct {
+	handle *memoize.Handle
+}
+
+type modWhyIndirectData struct {
+	// why keeps track of the `go mod why` results for each require statement
+	// in the go.mod file.
+	why map[string]string
+
+	// unused records the requires that `go mod why` reports as not needed by
+	// the main module at all, so that callers can offer a "remove unused
+	// require" fix.
+	unused map[string]bool
+
+	err error
+}
+
+func (mwh *modWhyIndirectHandle) why(ctx context.Context, snapshot *snapshot) (map[string]string, map[string]bool, error) {
+	v, err := mwh.handle.Get(ctx, snapshot.generation, snapshot)
+	if err != nil {
+		return nil, nil, err
+	}
+	data := v.(*modWhyIndirectData)
+	return data.why, data.unused, data.err
+}
+
+// notNeededPrefix is the prefix `go mod why` uses to report that a module is
+// not actually needed by the main module.
+const notNeededPrefix = "(main module does not need"
+
+// ModWhyIndirect is like ModWhy, but runs `go mod why -m -vendor` so that
+// indirect requirements introduced only by test dependencies of dependencies
+// are correctly explained. If the module has no vendor directory, -vendor is
+// omitted, since it has no effect in module mode.
+func (s *snapshot) ModWhyIndirect(ctx context.Context, fh source.FileHandle) (map[string]string, map[string]bool, error) {
+	if fh.Kind() != source.Mod {
+		return nil, nil, fmt.Errorf("%s is not a go.mod file", fh.URI())
+	}
+	if handle := s.getModWhyIndirectHandle(fh.URI()); handle != nil {
+		return handle.why(ctx, s)
+	}
+	key := modKey{
+		sessionID: s.view.session.id,
+		env:       hashEnv(s),
+		mod:       fh.FileIdentity(),
+		view:      s.view.rootURI.Filename(),
+		verb:      whyIndirect,
+	}
+	h := s.generation.Bind(key, func(ctx context.Context, arg memoize.Arg) interface{} {
+		ctx, done := event.Start(ctx, "cache.ModWhyIndirectHandle", tag.URI.Of(fh.URI()))
+		defer done()
+
+		snapshot := arg.(*snapshot)
+
+		pm, err := snapshot.ParseMod(ctx, fh)
+		if err != nil {
+			return &modWhyIndirectData{err: err}
+		}
+		// No requires to explain.
+		if len(pm.File.Require) == 0 {
+			return &modWhyIndirectData{}
+		}
+		args := []string{"why", "-m"}
+		if containsVendor(fh.URI()) {
+			args = append(args, "-vendor")
+		}
+		inv := &gocommand.Invocation{
+			Verb:       "mod",
+			Args:       args,
+			WorkingDir: filepath.Dir(fh.URI().Filename()),
+		}
+		for _, req := range pm.File.Require {
+			inv.Args = append(inv.Args, req.Mod.Path)
+		}
+		stdout, err := snapshot.RunGoCommandDirect(ctx, source.Normal, inv)
+		if err != nil {
+			return &modWhyIndirectData{err: err}
+		}
+		whyList := strings.Split(stdout.String(), "\n\n")
+		if len(whyList) != len(pm.File.Require) {
+			return &modWhyIndirectData{
+				err: fmt.Errorf("mismatched number of results: got %v, want %v", len(whyList), len(pm.File.Require)),
+			}
+		}
+		why := make(map[string]string, len(pm.File.Require))
+		unused := make(map[string]bool)
+		for i, req := range pm.File.Require {
+			result := whyList[i]
+			why[req.Mod.Path] = result
+			if strings.HasPrefix(strings.TrimSpace(result), notNeededPrefix) {
+				unused[req.Mod.Path] = true
+			}
+		}
+		return &modWhyIndirectData{why: why, unused: unused}
+	}, nil)
+
+	mwh := &modWhyIndirectHandle{handle: h}
+	s.mu.Lock()
+	s.modWhyIndirectHandles[fh.URI()] = mwh
+	s.mu.Unlock()
+
+	return mwh.why(ctx, s)
+}
+
 // containsVendor reports whether the module has a vendor folder.
 func containsVendor(modURI span.URI) bool {
 	dir := filepath.Dir(modURI.Filename())
@@ -392,8 +654,12 @@ func (s *snapshot) matchErrorToModule(ctx context.Context, fh source.FileHandle,
 	if err != nil {
 		return nil
 	}
-	toSourceError := func(line *modfile.Line) *source.Error {
-		rng, err := rangeFromPositions(pm.Mapper, line.Start, line.End)
+	// toSourceError builds the *source.Error for a match found at line, in
+	// the file described by mapper/uri -- either the go.mod being checked,
+	// or, for a workspace-level replace, the go.work that resolved it --
+	// attaching whatever suggested fix the error text calls for.
+	toSourceError := func(mapper *protocol.ColumnMapper, uri span.URI, line *modfile.Line) *source.Error {
+		rng, err := rangeFromPositions(mapper, line.Start, line.End)
 		if err != nil {
 			return nil
 		}
@@ -412,7 +678,7 @@ func (s *snapshot) matchErrorToModule(ctx context.Context, fh source.FileHandle,
 				Message: msg,
 				Kind:    source.ListError,
 				Range:   rng,
-				URI:     fh.URI(),
+				URI:     uri,
 				SuggestedFixes: []source.SuggestedFix{{
 					Title: fmt.Sprintf("Download %v@%v", v.Path, v.Version),
 					Command: &protocol.Command{
@@ -423,39 +689,87 @@ func (s *snapshot) matchErrorToModule(ctx context.Context, fh source.FileHandle,
 				}},
 			}
 		}
+		if v.Path != "" && strings.Contains(goCmdError, "missing go.sum entry") {
+			// If we already have the go.sum entry, there's nothing to suggest:
+			// the user needs to re-run the command that reported the error, not
+			// re-download the module.
+			if sum := s.goSum(ctx, fh.URI()); sum != nil && hasGoSumEntry(sum, v) {
+				return &source.Error{
+					Message: goCmdError,
+					Range:   rng,
+					URI:     uri,
+					Kind:    source.ListError,
+				}
+			}
+			args, err := source.MarshalArgs(fh.URI(), false, []string{fmt.Sprintf("%v@%v", v.Path, v.Version)})
+			if err != nil {
+				return nil
+			}
+			return &source.Error{
+				Message: goCmdError,
+				Kind:    source.ListError,
+				Range:   rng,
+				URI:     uri,
+				SuggestedFixes: []source.SuggestedFix{{
+					Title: fmt.Sprintf("Add go.sum entry for %v@%v", v.Path, v.Version),
+					Command: &protocol.Command{
+						Title:     source.CommandModDownload.Title,
+						Command:   source.CommandModDownload.ID(),
+						Arguments: args,
+					},
+				}},
+			}
+		}
 		return &source.Error{
 			Message: goCmdError,
 			Range:   rng,
-			URI:     fh.URI(),
+			URI:     uri,
 			Kind:    source.ListError,
 		}
 	}
+	// If this module is resolved via a workspace-level replace in go.work,
+	// pin the error to that line rather than an arbitrary go.mod, since the
+	// go.mod for this module may not even mention the module at all. Route
+	// it through toSourceError like every other match, so a workspace
+	// replace doesn't lose the suggested fix a go.mod replace would get.
+	if workFH, ok := s.workFile(ctx); ok {
+		if pw, err := s.ParseWork(ctx, workFH); err == nil && pw.File != nil {
+			for _, rep := range pw.File.Replace {
+				if rep.New != v && rep.Old != v {
+					continue
+				}
+				if srcErr := toSourceError(pw.Mapper, workFH.URI(), rep.Syntax); srcErr != nil {
+					return srcErr
+				}
+			}
+		}
+	}
 	// Check if there are any require, exclude, or replace statements that
 	// match this module version.
 	for _, req := range pm.File.Require {
 		if req.Mod != v {
 			continue
 		}
-		return toSourceError(req.Syntax)
+		return toSourceError(pm.Mapper, fh.URI(), req.Syntax)
 	}
 	for _, ex := range pm.File.Exclude {
 		if ex.Mod != v {
 			continue
 		}
-		return toSourceError(ex.Syntax)
+		return toSourceError(pm.Mapper, fh.URI(), ex.Syntax)
 	}
 	for _, rep := range pm.File.Replace {
 		if rep.New != v && rep.Old != v {
 			continue
 		}
-		return toSourceError(rep.Syntax)
+		return toSourceError(pm.Mapper, fh.URI(), rep.Syntax)
 	}
 	// No match for the module path was found in the go.mod file.
 	// Show the error on the module declaration, if one exists.
 	if pm.File.Module == nil {
 		return nil
 	}
-	return toSourceError(pm.File.Module.Syntax)
+	return toSourceError(pm.Mapper, fh.URI(), pm.File.Module.Syntax)
 }
 
 // errorPositionRe matches errors messages of the form <filename>:<line>:<col>,
@@ -0,0 +1,61 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOlderVersions(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  string
+		versions []string
+		want     []string
+	}{
+		{
+			name:     "splits older from newer",
+			current:  "v1.2.0",
+			versions: []string{"v1.0.0", "v1.1.0", "v1.2.0", "v1.3.0"},
+			want:     []string{"v1.0.0", "v1.1.0"},
+		},
+		{
+			name:     "no older versions",
+			current:  "v1.0.0",
+			versions: []string{"v1.0.0", "v1.1.0"},
+			want:     nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := olderVersions(test.current, test.versions)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("olderVersions(%q, %v) = %v, want %v", test.current, test.versions, got, test.want)
+			}
+		})
+	}
+}
+
+// TestNotNeededPrefix mirrors the unused-require detection in
+// ModWhyIndirect: a `go mod why` result is treated as "unused" exactly
+// when, after trimming whitespace, it starts with notNeededPrefix.
+func TestNotNeededPrefix(t *testing.T) {
+	tests := []struct {
+		result string
+		want   bool
+	}{
+		{"(main module does not need module example.com/foo)", true},
+		{"  (main module does not need module example.com/foo)\n", true},
+		{"example.com/foo\nexample.com/bar\n\texample.com/foo", false},
+	}
+	for _, test := range tests {
+		got := strings.HasPrefix(strings.TrimSpace(test.result), notNeededPrefix)
+		if got != test.want {
+			t.Errorf("unused(%q) = %v, want %v", test.result, got, test.want)
+		}
+	}
+}
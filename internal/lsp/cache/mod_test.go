@@ -0,0 +1,64 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	"golang.org/x/mod/module"
+)
+
+func TestHasGoSumEntry(t *testing.T) {
+	sum := []byte(`example.com/foo v1.2.3 h1:abc=
+example.com/foo v1.2.3/go.mod h1:def=
+example.com/bar v0.1.0/go.mod h1:ghi=
+`)
+	tests := []struct {
+		name string
+		mod  module.Version
+		want bool
+	}{
+		{"present module and version", module.Version{Path: "example.com/foo", Version: "v1.2.3"}, true},
+		{"present module, other version", module.Version{Path: "example.com/foo", Version: "v1.2.4"}, false},
+		{"absent module", module.Version{Path: "example.com/baz", Version: "v1.0.0"}, false},
+		{"go.mod-only entry still counts", module.Version{Path: "example.com/bar", Version: "v0.1.0"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := hasGoSumEntry(sum, test.mod); got != test.want {
+				t.Errorf("hasGoSumEntry(%v) = %v, want %v", test.mod, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMissingGoSumEntryMatchesModuleAtVersion(t *testing.T) {
+	// Regression test for the "missing go.sum entry" diagnostic: the
+	// module@version named in the `go mod download` suggestion must be
+	// extractable by the same module@version field scan matchErrorToModule
+	// uses for every other go command error.
+	msg := `missing go.sum entry for module providing package example.com/foo/bar; to add: go mod download example.com/foo@v1.2.3`
+	var field string
+	for _, f := range strings.FieldsFunc(msg, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ':'
+	}) {
+		if moduleAtVersionRe.MatchString(f) {
+			field = f
+			break
+		}
+	}
+	match := moduleAtVersionRe.FindStringSubmatch(field)
+	if match == nil {
+		t.Fatalf("moduleAtVersionRe did not match module@version extracted from %q", msg)
+	}
+	if got, want := match[1], "example.com/foo"; got != want {
+		t.Errorf("module = %q, want %q", got, want)
+	}
+	if got, want := match[2], "v1.2.3"; got != want {
+		t.Errorf("version = %q, want %q", got, want)
+	}
+}
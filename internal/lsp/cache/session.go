@@ -0,0 +1,17 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+// session represents the state of a single client connection to gopls: an
+// identity shared by every view the client has open, plus the cache that
+// backs file contents for all of them.
+type session struct {
+	// id is unique across all sessions started by this process, and is
+	// folded into keys -- such as modKey -- for data memoized per
+	// (session, view) pair.
+	id string
+
+	cache *cache
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"sync"
+
+	"golang.org/x/tools/internal/memoize"
+	"golang.org/x/tools/internal/span"
+)
+
+// snapshot represents the current state of a view: the set of go.mod,
+// go.work, and go.sum files it knows about, along with memoized results --
+// such as parsed mod/work files and the output of `go mod why`, `go list
+// -m -u`, and similar commands -- that are derived from them. A snapshot
+// is immutable; a new one is cloned whenever the set of files it tracks
+// changes.
+type snapshot struct {
+	mu sync.Mutex
+
+	generation *memoize.Generation
+
+	view *view
+
+	// parseModHandles keeps track of any ongoing go.mod parses.
+	parseModHandles map[span.URI]*parseModHandle
+
+	// parseWorkHandles keeps track of any ongoing go.work parses.
+	parseWorkHandles map[span.URI]*parseWorkHandle
+
+	// modWhyHandles keeps track of any ongoing `go mod why` calls.
+	modWhyHandles map[span.URI]*modWhyHandle
+
+	// modUpgradeHandles keeps track of any ongoing `go list -m -u` calls.
+	modUpgradeHandles map[span.URI]*modUpgradeHandle
+
+	// modDowngradeHandles keeps track of any ongoing `go list -m -versions`
+	// calls.
+	modDowngradeHandles map[span.URI]*modDowngradeHandle
+
+	// modWhyIndirectHandles keeps track of any ongoing `go mod why -m
+	// -vendor` calls.
+	modWhyIndirectHandles map[span.URI]*modWhyIndirectHandle
+}
+
+func (s *snapshot) getParseModHandle(uri span.URI) *parseModHandle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.parseModHandles[uri]
+}
+
+func (s *snapshot) getParseWorkHandle(uri span.URI) *parseWorkHandle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.parseWorkHandles[uri]
+}
+
+func (s *snapshot) getModWhyHandle(uri span.URI) *modWhyHandle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.modWhyHandles[uri]
+}
+
+func (s *snapshot) getModUpgradeHandle(uri span.URI) *modUpgradeHandle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.modUpgradeHandles[uri]
+}
+
+func (s *snapshot) getModDowngradeHandle(uri span.URI) *modDowngradeHandle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.modDowngradeHandles[uri]
+}
+
+func (s *snapshot) getModWhyIndirectHandle(uri span.URI) *modWhyIndirectHandle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.modWhyIndirectHandles[uri]
+}
+
+// clone copies s's handles into a new snapshot, as happens whenever the set
+// of files tracked by a view changes. Handles are carried forward
+// unmodified; callers that need to invalidate a handle because its
+// underlying file changed are expected to delete it from the clone.
+func (s *snapshot) clone() *snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := &snapshot{
+		view:                  s.view,
+		generation:            s.generation,
+		parseModHandles:       make(map[span.URI]*parseModHandle, len(s.parseModHandles)),
+		parseWorkHandles:      make(map[span.URI]*parseWorkHandle, len(s.parseWorkHandles)),
+		modWhyHandles:         make(map[span.URI]*modWhyHandle, len(s.modWhyHandles)),
+		modUpgradeHandles:     make(map[span.URI]*modUpgradeHandle, len(s.modUpgradeHandles)),
+		modDowngradeHandles:   make(map[span.URI]*modDowngradeHandle, len(s.modDowngradeHandles)),
+		modWhyIndirectHandles: make(map[span.URI]*modWhyIndirectHandle, len(s.modWhyIndirectHandles)),
+	}
+	for k, v := range s.parseModHandles {
+		result.parseModHandles[k] = v
+	}
+	for k, v := range s.parseWorkHandles {
+		result.parseWorkHandles[k] = v
+	}
+	for k, v := range s.modWhyHandles {
+		result.modWhyHandles[k] = v
+	}
+	for k, v := range s.modUpgradeHandles {
+		result.modUpgradeHandles[k] = v
+	}
+	for k, v := range s.modDowngradeHandles {
+		result.modDowngradeHandles[k] = v
+	}
+	for k, v := range s.modWhyIndirectHandles {
+		result.modWhyIndirectHandles[k] = v
+	}
+	return result
+}
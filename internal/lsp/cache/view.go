@@ -0,0 +1,64 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"sync"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// view holds the state, beyond what's tracked per-snapshot, needed to
+// resolve a go.work-based workspace: the location of the view's go.work
+// file, if any, and the directories it has pulled in via `use` directives.
+type view struct {
+	mu sync.Mutex
+
+	// session is the session that created this view, shared across every
+	// view the session has open.
+	session *session
+
+	// rootURI is the root directory of the view's primary module, the one
+	// it was created for.
+	rootURI span.URI
+
+	// workURI is the location of the view's go.work file. It is empty if
+	// the view isn't operating in workspace (go.work) mode.
+	workURI span.URI
+
+	// workspaceModDirs is the set of directories named by `use` directives
+	// in the go.work file at workURI, refreshed by
+	// (*snapshot).WorkspaceModFiles each time the workspace is reloaded, so
+	// that every used module is treated as a first-class module of the
+	// view rather than just the one the view was opened on.
+	workspaceModDirs []string
+}
+
+// workspaceFileURI returns the URI of the view's go.work file, if the view
+// is in workspace mode.
+func (v *view) workspaceFileURI() (span.URI, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.workURI == "" {
+		return "", false
+	}
+	return v.workURI, true
+}
+
+// setWorkspaceModDirs records the directories composed into the view's
+// workspace by the most recent reload of its go.work file.
+func (v *view) setWorkspaceModDirs(dirs []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.workspaceModDirs = dirs
+}
+
+// setWorkspaceFileURI records the location of the go.work file discovered
+// for the view, switching it into workspace mode.
+func (v *view) setWorkspaceFileURI(uri span.URI) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.workURI = uri
+}
@@ -0,0 +1,317 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/memoize"
+	"golang.org/x/tools/internal/span"
+)
+
+type parseWorkHandle struct {
+	handle *memoize.Handle
+}
+
+type parseWorkData struct {
+	parsed *source.ParsedWorkFile
+
+	// err is any error encountered while parsing the file.
+	err error
+}
+
+func (wh *parseWorkHandle) parse(ctx context.Context, snapshot *snapshot) (*source.ParsedWorkFile, error) {
+	v, err := wh.handle.Get(ctx, snapshot.generation, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	data := v.(*parseWorkData)
+	return data.parsed, data.err
+}
+
+// ParseWork parses the go.work file at workFH.URI(), memoizing the result
+// exactly as ParseMod does for go.mod files. The returned ParseErrors
+// include both syntax errors and, for a syntactically valid file, one
+// error per `use` directive whose directory doesn't exist or doesn't
+// contain a go.mod file -- the same channel client diagnostics for go.mod
+// are already driven from.
+func (s *snapshot) ParseWork(ctx context.Context, workFH source.FileHandle) (*source.ParsedWorkFile, error) {
+	if handle := s.getParseWorkHandle(workFH.URI()); handle != nil {
+		return handle.parse(ctx, s)
+	}
+	h := s.generation.Bind(workFH.FileIdentity(), func(ctx context.Context, _ memoize.Arg) interface{} {
+		_, done := event.Start(ctx, "cache.ParseWorkHandle", tag.URI.Of(workFH.URI()))
+		defer done()
+
+		contents, err := workFH.Read()
+		if err != nil {
+			return &parseWorkData{err: err}
+		}
+		m := &protocol.ColumnMapper{
+			URI:       workFH.URI(),
+			Converter: span.NewContentConverter(workFH.URI().Filename(), contents),
+			Content:   contents,
+		}
+		file, err := modfile.ParseWork(workFH.URI().Filename(), contents, nil)
+
+		// Attempt to convert the error to a standardized parse error.
+		var parseErrors []*source.Error
+		if err != nil {
+			if parseErr := extractErrorWithPosition(ctx, err.Error(), s); parseErr != nil {
+				parseErrors = []*source.Error{parseErr}
+			}
+		} else {
+			useErrs, err := useDirectiveErrors(workFH.URI(), m, file, filepath.Dir(workFH.URI().Filename()))
+			if err != nil {
+				return &parseWorkData{err: err}
+			}
+			parseErrors = useErrs
+		}
+		return &parseWorkData{
+			parsed: &source.ParsedWorkFile{
+				URI:         workFH.URI(),
+				Mapper:      m,
+				File:        file,
+				ParseErrors: parseErrors,
+			},
+			err: err,
+		}
+	}, nil)
+
+	wh := &parseWorkHandle{handle: h}
+	s.mu.Lock()
+	s.parseWorkHandles[workFH.URI()] = wh
+	s.mu.Unlock()
+
+	return wh.parse(ctx, s)
+}
+
+// resolveUseDir resolves a `use` directive's path against workDir, as Go
+// does: relative paths are interpreted relative to the directory
+// containing the go.work file.
+func resolveUseDir(workDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(workDir, path)
+}
+
+// useDirProblem classifies why a `use` directive's directory can't be
+// composed into the workspace, or "" if it can.
+func useDirProblem(dir string) string {
+	info, err := os.Stat(dir)
+	switch {
+	case err != nil || !info.IsDir():
+		return "does not exist"
+	case !hasGoMod(dir):
+		return "does not contain a go.mod file"
+	default:
+		return ""
+	}
+}
+
+// hasGoMod reports whether dir contains a go.mod file.
+func hasGoMod(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "go.mod"))
+	return err == nil
+}
+
+// useDirectiveErrors returns one *source.Error per `use` directive in file
+// that names a directory that doesn't exist or doesn't contain a go.mod
+// file, relative to workDir.
+func useDirectiveErrors(workURI span.URI, m *protocol.ColumnMapper, file *modfile.WorkFile, workDir string) ([]*source.Error, error) {
+	if file == nil {
+		return nil, nil
+	}
+	var errs []*source.Error
+	for _, use := range file.Use {
+		problem := useDirProblem(resolveUseDir(workDir, use.Path))
+		if problem == "" {
+			continue
+		}
+		rng, err := rangeFromPositions(m, use.Syntax.Start, use.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, &source.Error{
+			URI:     workURI,
+			Range:   rng,
+			Kind:    source.ListError,
+			Message: fmt.Sprintf("directory %q %s", use.Path, problem),
+		})
+	}
+	return errs, nil
+}
+
+// workUseDirs returns the absolute directories named by valid use
+// directives of the go.work file at workFH.URI() -- i.e. those that exist
+// and contain a go.mod file. Directories that fail those checks are
+// omitted here and reported instead through ParseWork's ParseErrors.
+func (s *snapshot) workUseDirs(ctx context.Context, workFH source.FileHandle) ([]string, error) {
+	pw, err := s.ParseWork(ctx, workFH)
+	if err != nil {
+		return nil, err
+	}
+	if pw.File == nil {
+		return nil, nil
+	}
+	workDir := filepath.Dir(workFH.URI().Filename())
+	var dirs []string
+	for _, use := range pw.File.Use {
+		dir := resolveUseDir(workDir, use.Path)
+		if useDirProblem(dir) != "" {
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// workReplaceModule reports the workspace-level replace directive in the
+// go.work file at workFH.URI() that applies to mod, if any, so that callers
+// building the temp module file for the workspace can honor go.work
+// replaces in addition to the per-module ones.
+func (s *snapshot) workReplaceModule(ctx context.Context, workFH source.FileHandle, mod string) (*modfile.Replace, error) {
+	pw, err := s.ParseWork(ctx, workFH)
+	if err != nil {
+		return nil, err
+	}
+	if pw.File == nil {
+		return nil, nil
+	}
+	for _, rep := range pw.File.Replace {
+		if rep.Old.Path == mod {
+			return rep, nil
+		}
+	}
+	return nil, nil
+}
+
+// findWorkFile reports the go.work file in dir, if one exists.
+func findWorkFile(dir string) (span.URI, bool) {
+	path := filepath.Join(dir, "go.work")
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return span.URIFromPath(path), true
+}
+
+// workFile returns the FileHandle for the view's go.work file, discovering
+// it in the view's root directory and switching the view into workspace
+// mode on first use if it hasn't been located yet. It returns false if the
+// view's root doesn't contain a go.work file.
+func (s *snapshot) workFile(ctx context.Context) (source.FileHandle, bool) {
+	workURI, ok := s.view.workspaceFileURI()
+	if !ok {
+		found, ok := findWorkFile(s.view.rootURI.Filename())
+		if !ok {
+			return nil, false
+		}
+		s.view.setWorkspaceFileURI(found)
+		workURI = found
+	}
+	fh, err := s.GetFile(ctx, workURI)
+	if err != nil {
+		return nil, false
+	}
+	return fh, true
+}
+
+// WorkspaceModFiles returns the FileHandle for every go.mod file composed
+// into the current view's workspace: the one the view was opened on, plus
+// one per directory named by a `use` directive in go.work, if the view is
+// in workspace mode. ParseMod, ModWhy, and ModUpgrade can be run against
+// each of the returned handles to treat every used module as a first-class
+// module of the view, as opposed to just the root one.
+func (s *snapshot) WorkspaceModFiles(ctx context.Context, rootModURI span.URI) (map[span.URI]source.FileHandle, error) {
+	modFiles := map[span.URI]source.FileHandle{}
+	if rootModURI != "" {
+		fh, err := s.GetFile(ctx, rootModURI)
+		if err != nil {
+			return nil, err
+		}
+		modFiles[rootModURI] = fh
+	}
+	workFH, ok := s.workFile(ctx)
+	if !ok {
+		return modFiles, nil
+	}
+	dirs, err := s.workUseDirs(ctx, workFH)
+	if err != nil {
+		return nil, err
+	}
+	s.view.setWorkspaceModDirs(dirs)
+	for _, dir := range dirs {
+		uri := span.URIFromPath(filepath.Join(dir, "go.mod"))
+		if _, ok := modFiles[uri]; ok {
+			continue
+		}
+		fh, err := s.GetFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		modFiles[uri] = fh
+	}
+	return modFiles, nil
+}
+
+// tempWorkspaceModFile writes a copy of the go.mod file parsed as pm to a
+// temporary file with any workspace-level replace directives from the
+// view's go.work file appended -- one per require that go.work itself
+// replaces -- so that a go command invoked with `-modfile` set to the
+// result honors go.work replaces without mutating the real go.mod. It
+// returns the empty string if the view isn't in workspace mode or go.work
+// doesn't replace any of fh's requires.
+func (s *snapshot) tempWorkspaceModFile(ctx context.Context, fh source.FileHandle, pm *source.ParsedModule) (string, error) {
+	workFH, ok := s.workFile(ctx)
+	if !ok {
+		return "", nil
+	}
+	var extra []*modfile.Replace
+	for _, req := range pm.File.Require {
+		rep, err := s.workReplaceModule(ctx, workFH, req.Mod.Path)
+		if err != nil {
+			return "", err
+		}
+		if rep != nil {
+			extra = append(extra, rep)
+		}
+	}
+	if len(extra) == 0 {
+		return "", nil
+	}
+	copied, err := modfile.Parse(fh.URI().Filename(), pm.Mapper.Content, nil)
+	if err != nil {
+		return "", err
+	}
+	for _, rep := range extra {
+		if err := copied.AddReplace(rep.Old.Path, rep.Old.Version, rep.New.Path, rep.New.Version); err != nil {
+			return "", err
+		}
+	}
+	copied.Cleanup()
+	data, err := copied.Format()
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(fh.URI().Filename()), "gopls-*.mod")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+	if _, err := tmp.Write(data); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
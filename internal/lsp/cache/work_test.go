@@ -0,0 +1,62 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveUseDir(t *testing.T) {
+	tests := []struct {
+		workDir, path, want string
+	}{
+		{"/work", "./foo", "/work/foo"},
+		{"/work", "bar", "/work/bar"},
+		{"/work", "/abs/baz", "/abs/baz"},
+	}
+	for _, test := range tests {
+		if got := resolveUseDir(test.workDir, test.path); got != filepath.Clean(test.want) {
+			t.Errorf("resolveUseDir(%q, %q) = %q, want %q", test.workDir, test.path, got, test.want)
+		}
+	}
+}
+
+func TestUseDirProblem(t *testing.T) {
+	root := t.TempDir()
+
+	withGoMod := filepath.Join(root, "withgomod")
+	if err := os.MkdirAll(withGoMod, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(withGoMod, "go.mod"), []byte("module example.com/a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	withoutGoMod := filepath.Join(root, "withoutgomod")
+	if err := os.MkdirAll(withoutGoMod, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := filepath.Join(root, "does-not-exist")
+
+	tests := []struct {
+		name string
+		dir  string
+		want string
+	}{
+		{"valid module directory", withGoMod, ""},
+		{"directory missing go.mod", withoutGoMod, "does not contain a go.mod file"},
+		{"directory does not exist", missing, "does not exist"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := useDirProblem(test.dir); got != test.want {
+				t.Errorf("useDirProblem(%q) = %q, want %q", test.dir, got, test.want)
+			}
+		})
+	}
+}
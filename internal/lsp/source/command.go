@@ -0,0 +1,44 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+// Command describes an LSP command that gopls implements: a stable name
+// the client echoes back in ExecuteCommand requests, and a human-readable
+// title for use in code action menus.
+type Command struct {
+	Title string
+	Name  string
+}
+
+// CommandPrefix is the prefix of all command names gopls uses externally.
+const CommandPrefix = "gopls."
+
+// ID adds the CommandPrefix to the command name, in order to avoid
+// collisions with other language servers.
+func (c Command) ID() string {
+	return CommandPrefix + c.Name
+}
+
+// Commands are the commands currently supported by gopls.
+var Commands = []*Command{
+	CommandAddDependency,
+	CommandModDownload,
+}
+
+var (
+	// CommandAddDependency adds a dependency.
+	CommandAddDependency = &Command{
+		Name:  "add_dependency",
+		Title: "Add dependency",
+	}
+
+	// CommandModDownload runs `go mod download` for a module reported as
+	// missing a go.sum entry, which writes the entry to go.sum on disk.
+	// It does not refresh a go.sum that the snapshot already tracks.
+	CommandModDownload = &Command{
+		Name:  "mod_download",
+		Title: "Add go.sum entry",
+	}
+)